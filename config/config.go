@@ -0,0 +1,49 @@
+package config
+
+import (
+	"sync"
+	"time"
+)
+
+// Configuration is the root of the Wings configuration file.
+type Configuration struct {
+	System SystemConfiguration `json:"system" yaml:"system"`
+	// PanelLocation is the base URL of the Panel this node reports to,
+	// used for outbound requests such as delivering batched audit records.
+	PanelLocation string `json:"remote" yaml:"remote"`
+	// AuthenticationToken authenticates this node's outbound requests to
+	// the Panel.
+	AuthenticationToken string `json:"token" yaml:"token"`
+}
+
+var (
+	mu  sync.RWMutex
+	cfg = &Configuration{
+		System: SystemConfiguration{
+			Websocket: WebsocketConfiguration{
+				PingInterval: 30 * time.Second,
+				PongTimeout:  60 * time.Second,
+				RateLimit: RateLimitConfiguration{
+					EventsPerSecond: 10,
+					Burst:           20,
+					MaxInFlight:     4,
+				},
+			},
+		},
+	}
+)
+
+// Get returns the currently loaded global configuration instance.
+func Get() *Configuration {
+	mu.RLock()
+	defer mu.RUnlock()
+	return cfg
+}
+
+// Set overwrites the global configuration instance, for example after
+// reloading it from disk.
+func Set(c *Configuration) {
+	mu.Lock()
+	defer mu.Unlock()
+	cfg = c
+}
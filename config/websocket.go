@@ -0,0 +1,88 @@
+package config
+
+import "time"
+
+// SystemConfiguration defines basic system configuration settings.
+type SystemConfiguration struct {
+	// Websocket holds the tunables for the per-server console/stats
+	// websocket connections exposed by Wings. Operators running behind an
+	// L7 proxy that kills idle connections aggressively will want to lower
+	// these; those with flaky clients on high-latency links may want to
+	// raise them.
+	Websocket WebsocketConfiguration `json:"websocket" yaml:"websocket"`
+}
+
+// WebsocketConfiguration controls keepalive and backpressure behavior for
+// server websocket connections.
+type WebsocketConfiguration struct {
+	// PingInterval is how often the server sends a ping control frame to an
+	// open connection to verify the peer is still alive.
+	PingInterval time.Duration `default:"30s" json:"ping_interval" yaml:"ping_interval"`
+	// PongTimeout is how long the server will wait for a pong in response to
+	// a ping before treating the connection as dead.
+	PongTimeout time.Duration `default:"60s" json:"pong_timeout" yaml:"pong_timeout"`
+	// RateLimit bounds how many inbound events a single connection may send,
+	// protecting a node from a single authenticated client flooding it with
+	// "send command" or "send stats" events.
+	RateLimit RateLimitConfiguration `json:"rate_limit" yaml:"rate_limit"`
+	// Audit controls whether, and where, a structured record of websocket
+	// session activity (connection open/close, dispatched events) is kept.
+	Audit AuditConfiguration `json:"audit" yaml:"audit"`
+	// TrustedProxies lists the IPs/CIDRs of reverse proxies (e.g. the
+	// Panel's load balancer) allowed to set X-Forwarded-For/X-Real-IP on
+	// requests that reach Wings. Anything not in this list has its
+	// RemoteAddr used as-is, so a client can't spoof its own audited IP by
+	// sending those headers directly.
+	TrustedProxies []string `json:"trusted_proxies" yaml:"trusted_proxies"`
+}
+
+// AuditConfiguration controls how a structured record of websocket session
+// activity is kept for later investigation, e.g. "who ran this command in
+// the console." Both sinks may be enabled at once.
+type AuditConfiguration struct {
+	// Enabled turns on the JSON-lines file sink.
+	Enabled bool `default:"false" json:"enabled" yaml:"enabled"`
+	// FilePath is where audit records are appended as JSON-lines.
+	FilePath string `default:"/var/log/pterodactyl/wings-websocket-audit.log" json:"file_path" yaml:"file_path"`
+	// Panel controls delivering the same records to the Panel instead of
+	// (or in addition to) the local file.
+	Panel PanelAuditConfiguration `json:"panel" yaml:"panel"`
+}
+
+// PanelAuditConfiguration controls batching and delivery of websocket audit
+// records to the Panel.
+type PanelAuditConfiguration struct {
+	// Enabled turns on POSTing batched audit records to the Panel.
+	Enabled bool `default:"false" json:"enabled" yaml:"enabled"`
+	// BatchSize is how many records are held before being flushed early.
+	BatchSize int `default:"50" json:"batch_size" yaml:"batch_size"`
+	// FlushInterval is the maximum time a record waits before being sent,
+	// even if BatchSize hasn't been reached.
+	FlushInterval time.Duration `default:"10s" json:"flush_interval" yaml:"flush_interval"`
+}
+
+// RateLimitConfiguration controls inbound event throttling for a single
+// websocket connection.
+type RateLimitConfiguration struct {
+	// EventsPerSecond is the default sustained rate of inbound events
+	// allowed per connection, used for any event type without an entry in
+	// PerEvent.
+	EventsPerSecond float64 `default:"10" json:"events_per_second" yaml:"events_per_second"`
+	// Burst is the default number of events a connection may send in a
+	// single burst above the sustained rate.
+	Burst int `default:"20" json:"burst" yaml:"burst"`
+	// PerEvent overrides EventsPerSecond/Burst for specific event names,
+	// for example allowing "send stats" far more often than "send command".
+	PerEvent map[string]EventRateLimitConfiguration `json:"per_event" yaml:"per_event"`
+	// MaxInFlight caps how many HandleInbound goroutines a single
+	// connection may have running at once, so a handful of slow handlers
+	// can't pile up unbounded work even when under the rate limit.
+	MaxInFlight int `default:"4" json:"max_in_flight" yaml:"max_in_flight"`
+}
+
+// EventRateLimitConfiguration overrides the default rate limit for a single
+// event type.
+type EventRateLimitConfiguration struct {
+	EventsPerSecond float64 `json:"events_per_second" yaml:"events_per_second"`
+	Burst           int     `json:"burst" yaml:"burst"`
+}
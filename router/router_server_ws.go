@@ -2,11 +2,15 @@ package router
 
 import (
 	"context"
-	"encoding/json"
+	"net"
+	"net/http"
+	"sync"
 
 	"emperror.dev/errors"
+	"github.com/apex/log"
 	"github.com/gin-gonic/gin"
 	ws "github.com/gorilla/websocket"
+	"github.com/pterodactyl/wings/config"
 	"github.com/pterodactyl/wings/router/middleware"
 	"github.com/pterodactyl/wings/router/websocket"
 	"github.com/pterodactyl/wings/server"
@@ -20,6 +24,52 @@ var expectedCloseCodes = []int{
 	ws.CloseServiceRestart,
 }
 
+// maxRateLimitViolations is how many consecutive inbound events a
+// connection may have rejected by its RateLimiter before it's treated as
+// abusive rather than merely bursty, and disconnected outright.
+const maxRateLimitViolations = 10
+
+var (
+	auditSinkOnce sync.Once
+	auditSink     websocket.AuditSink
+)
+
+// getAuditSink lazily builds the configured audit sink(s) the first time a
+// websocket connection is opened, so that nodes with auditing disabled
+// never touch the filesystem or make outbound requests for it. The file and
+// Panel sinks are independent and may both be enabled at once.
+func getAuditSink() websocket.AuditSink {
+	auditSinkOnce.Do(func() {
+		cfg := config.Get().System.Websocket.Audit
+		var sinks websocket.MultiAuditSink
+
+		if cfg.Enabled {
+			sink, err := websocket.NewFileAuditSink(cfg.FilePath)
+			if err != nil {
+				log.WithField("error", err).WithField("path", cfg.FilePath).Error("failed to open websocket audit sink")
+			} else {
+				sinks = append(sinks, sink)
+			}
+		}
+
+		if cfg.Panel.Enabled {
+			c := config.Get()
+			sinks = append(sinks, websocket.NewPanelAuditSink(
+				http.DefaultClient,
+				c.PanelLocation,
+				c.AuthenticationToken,
+				cfg.Panel.BatchSize,
+				cfg.Panel.FlushInterval,
+			))
+		}
+
+		if len(sinks) > 0 {
+			auditSink = sinks
+		}
+	})
+	return auditSink
+}
+
 // Upgrades a connection to a websocket and passes events along between.
 func getServerWebsocket(c *gin.Context) {
 	manager := middleware.ExtractManager(c)
@@ -38,21 +88,46 @@ func getServerWebsocket(c *gin.Context) {
 		return
 	}
 
-	// Track this open connection on the server so that we can close them all programmatically
-	// if the server is deleted.
-	s.Websockets().Push(handler.Uuid(), &cancel)
+	if sink := getAuditSink(); sink != nil {
+		handler.SetAuditSink(sink)
+	}
+
+	// Track this open connection on the server so that we can close them all programmatically,
+	// with a proper close handshake, if the server is deleted or Wings is shutting down.
+	closer := websocket.NewConnCloser(cancel)
+	s.Websockets().Push(handler.Uuid(), closer)
 	handler.Logger().Debug("opening connection to server websocket")
 	defer s.Websockets().Remove(handler.Uuid())
 
+	// Every outbound message (console output, stats, command replies, errors)
+	// must flow through the writer goroutine since gorilla/websocket forbids
+	// concurrent writers; if it can't keep up we'd rather close the socket
+	// than let producers block indefinitely.
+	handler.StartWriter(ctx, func() {
+		closer.Close(ws.CloseInternalServerErr, "server overloaded")
+	})
+
+	// Ping the client on an interval and track pongs via a read deadline so
+	// that half-open connections (idle-killing proxies, a client that
+	// vanished without a close frame) get torn down instead of leaking the
+	// goroutines above and the entry in s.Websockets() forever.
+	wsCfg := config.Get().System.Websocket
+	handler.StartKeepalive(ctx, wsCfg.PingInterval, wsCfg.PongTimeout, func() {
+		closer.Close(ws.CloseInternalServerErr, "keepalive ping failed")
+	})
+
 	go func() {
 		select {
-		// When the main context is canceled (through disconnect, server deletion, or server
-		// suspension) close the connection itself.
+		// When the main context is canceled (through disconnect, server deletion, Wings
+		// shutdown, or server suspension) perform a graceful close handshake rather than
+		// just severing the TCP connection out from under the client.
 		case <-ctx.Done():
 			handler.Logger().Debug("closing connection to server websocket")
-			if err := handler.Connection.Close(); err != nil {
+			code, reason := closer.Signal()
+			if err := handler.Close(code, reason); err != nil {
 				handler.Logger().WithError(err).Error("failed to close websocket connection")
 			}
+			handler.RecordClosed(code, reason)
 			break
 		}
 	}()
@@ -66,7 +141,7 @@ func getServerWebsocket(c *gin.Context) {
 		// the request context being closed to break this loop, otherwise this routine will
 		// be left hanging in the background.
 		case <-s.Context().Done():
-			cancel()
+			closer.Close(websocket.CloseServerDeleted, "server deleted")
 			break
 		}
 	}()
@@ -76,30 +151,74 @@ func getServerWebsocket(c *gin.Context) {
 	// the HTTP response in the websocket client, thus we connect and then
 	// immediately close with failure.
 	if s.IsSuspended() {
-		_ = handler.Connection.WriteMessage(ws.CloseMessage, ws.FormatCloseMessage(4409, "server is suspended"))
+		closer.Close(websocket.CloseSuspended, "server is suspended")
 
 		return
 	}
 
-	for {
-		j := websocket.Message{}
+	limiter := websocket.NewRateLimiter(wsCfg.RateLimit)
+	violations := 0
 
+	for {
 		_, p, err := handler.Connection.ReadMessage()
 		if err != nil {
-			if ws.IsUnexpectedCloseError(err, expectedCloseCodes...) {
+			if ne, ok := err.(net.Error); ok && ne.Timeout() {
+				handler.Logger().Warn("no pong received within the keepalive timeout, treating connection as dead")
+				closer.Close(ws.CloseInternalServerErr, "keepalive timeout")
+			} else if ws.IsUnexpectedCloseError(err, expectedCloseCodes...) {
 				handler.Logger().WithField("error", err).Warn("error handling websocket message for server")
 			}
 			break
 		}
+		handler.AddBytesIn(len(p))
 
-		// Discard and JSON parse errors into the void and don't continue processing this
+		// Discard decode errors into the void and don't continue processing this
 		// specific socket request. If we did a break here the client would get disconnected
 		// from the socket, which is NOT what we want to do.
-		if err := json.Unmarshal(p, &j); err != nil {
+		j, err := handler.Decode(p)
+		if err != nil {
+			continue
+		}
+
+		if !limiter.Allow(j.Event) {
+			violations++
+			if violations > maxRateLimitViolations {
+				handler.Logger().WithField("event", j.Event).Warn("connection repeatedly exceeded inbound rate limit, closing")
+				closer.Close(ws.ClosePolicyViolation, "rate limited")
+				break
+			}
+			_ = handler.SendJson(&websocket.Message{Event: "rate_limited", Args: []string{j.Event}})
 			continue
 		}
+		violations = 0
+		handler.RecordEventDispatched(j.Event)
 
+		// Authentication (and re-authentication, for a long-lived connection
+		// whose token is about to expire) arrives as a regular inbound event
+		// rather than through the HTTP upgrade request, so it's handled
+		// inline here instead of being handed off to HandleInbound.
+		if j.Event == websocket.AuthenticationEvent {
+			if len(j.Args) == 0 {
+				_ = handler.SendErrorJson(j, errors.New("websocket: auth event missing token argument"))
+				continue
+			}
+			uid, err := websocket.ParseAuthToken(j.Args[0])
+			if err != nil {
+				_ = handler.SendErrorJson(j, err)
+				continue
+			}
+			handler.SetUserID(uid)
+			continue
+		}
+
+		// Acquire the in-flight slot here, before spawning the goroutine,
+		// so a flood of events within the token-bucket rate still bounds
+		// concurrent HandleInbound executions rather than piling up a new
+		// blocked goroutine per message while slow handlers catch up.
+		release := limiter.Acquire()
 		go func(msg websocket.Message) {
+			defer release()
+
 			if err := handler.HandleInbound(ctx, msg); err != nil {
 				if errors.Is(err, server.ErrSuspended) {
 					cancel()
@@ -0,0 +1,208 @@
+package websocket
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/apex/log"
+)
+
+// AuditEventType identifies the stage of a connection's lifecycle an
+// AuditEvent was recorded for.
+type AuditEventType string
+
+const (
+	AuditConnectionOpened AuditEventType = "connection_opened"
+	AuditAuthenticated    AuditEventType = "authenticated"
+	AuditReauthenticated  AuditEventType = "reauthenticated"
+	AuditEventDispatched  AuditEventType = "event_dispatched"
+	AuditConnectionClosed AuditEventType = "connection_closed"
+)
+
+// AuditEvent is a single structured record of something happening on a
+// server websocket connection. This is what lets an operator answer "who
+// ran this command in the console, and when."
+type AuditEvent struct {
+	Type        AuditEventType `json:"type"`
+	Time        time.Time      `json:"time"`
+	ServerID    string         `json:"server_id"`
+	UserID      string         `json:"user_id,omitempty"`
+	RemoteIP    string         `json:"remote_ip"`
+	Subprotocol string         `json:"subprotocol"`
+	Socket      string         `json:"socket"`
+	Event       string         `json:"event,omitempty"`
+	CloseCode   int            `json:"close_code,omitempty"`
+	CloseReason string         `json:"close_reason,omitempty"`
+	BytesIn     uint64         `json:"bytes_in,omitempty"`
+	BytesOut    uint64         `json:"bytes_out,omitempty"`
+	Duration    time.Duration  `json:"duration,omitempty"`
+}
+
+// AuditSink receives every AuditEvent recorded for every server websocket
+// connection. Implementations must be safe for concurrent use.
+type AuditSink interface {
+	Record(e AuditEvent)
+}
+
+// MultiAuditSink fans a single AuditEvent out to every wrapped sink, so the
+// file and Panel sinks can both be active at once.
+type MultiAuditSink []AuditSink
+
+// Record forwards e to every wrapped sink.
+func (m MultiAuditSink) Record(e AuditEvent) {
+	for _, sink := range m {
+		sink.Record(e)
+	}
+}
+
+// FileAuditSink appends each AuditEvent to a file as a line of JSON.
+type FileAuditSink struct {
+	mu sync.Mutex
+	f  *os.File
+}
+
+// NewFileAuditSink opens (creating if necessary) the file at path for
+// appending JSON-lines audit records.
+func NewFileAuditSink(path string) (*FileAuditSink, error) {
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	return &FileAuditSink{f: f}, nil
+}
+
+// Record writes e to the sink's file as a single JSON line.
+func (s *FileAuditSink) Record(e AuditEvent) {
+	b, err := json.Marshal(e)
+	if err != nil {
+		return
+	}
+	b = append(b, '\n')
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, _ = s.f.Write(b)
+}
+
+// Close closes the underlying file.
+func (s *FileAuditSink) Close() error {
+	return s.f.Close()
+}
+
+// CallbackAuditSink batches audit records and periodically hands them to a
+// callback, typically one that POSTs them to the Panel.
+type CallbackAuditSink struct {
+	mu     sync.Mutex
+	batch  []AuditEvent
+	size   int
+	flush  func([]AuditEvent)
+	ticker *time.Ticker
+	done   chan struct{}
+}
+
+// NewCallbackAuditSink starts a background flusher that calls flush with up
+// to batchSize records at a time, and at least once per interval even if
+// the batch isn't full.
+func NewCallbackAuditSink(batchSize int, interval time.Duration, flush func([]AuditEvent)) *CallbackAuditSink {
+	s := &CallbackAuditSink{
+		size:   batchSize,
+		flush:  flush,
+		ticker: time.NewTicker(interval),
+		done:   make(chan struct{}),
+	}
+	go s.loop()
+	return s
+}
+
+func (s *CallbackAuditSink) loop() {
+	for {
+		select {
+		case <-s.done:
+			s.ticker.Stop()
+			return
+		case <-s.ticker.C:
+			s.flushBatch()
+		}
+	}
+}
+
+// Record appends e to the current batch, flushing immediately if that fills
+// the batch.
+func (s *CallbackAuditSink) Record(e AuditEvent) {
+	s.mu.Lock()
+	s.batch = append(s.batch, e)
+	var batch []AuditEvent
+	if len(s.batch) >= s.size {
+		batch = s.batch
+		s.batch = nil
+	}
+	s.mu.Unlock()
+
+	if batch != nil {
+		s.flush(batch)
+	}
+}
+
+func (s *CallbackAuditSink) flushBatch() {
+	s.mu.Lock()
+	if len(s.batch) == 0 {
+		s.mu.Unlock()
+		return
+	}
+	batch := s.batch
+	s.batch = nil
+	s.mu.Unlock()
+
+	s.flush(batch)
+}
+
+// Close stops the background flusher after flushing any records still
+// batched up.
+func (s *CallbackAuditSink) Close() error {
+	close(s.done)
+	s.flushBatch()
+	return nil
+}
+
+// panelAuditEndpoint is appended to the Panel's base URL to POST batched
+// websocket audit records.
+const panelAuditEndpoint = "/api/remote/websocket/audit"
+
+// NewPanelAuditSink returns a CallbackAuditSink whose flush callback POSTs
+// each batch of audit records to the Panel, authenticated the same way
+// Wings authenticates every other outbound request to it.
+func NewPanelAuditSink(client *http.Client, baseURL, token string, batchSize int, flushInterval time.Duration) *CallbackAuditSink {
+	url := strings.TrimRight(baseURL, "/") + panelAuditEndpoint
+
+	return NewCallbackAuditSink(batchSize, flushInterval, func(batch []AuditEvent) {
+		body, err := json.Marshal(batch)
+		if err != nil {
+			log.WithField("error", err).Error("failed to marshal websocket audit batch")
+			return
+		}
+
+		req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+		if err != nil {
+			log.WithField("error", err).Error("failed to build websocket audit request")
+			return
+		}
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+token)
+
+		resp, err := client.Do(req)
+		if err != nil {
+			log.WithField("error", err).Warn("failed to deliver websocket audit batch to the Panel")
+			return
+		}
+		_ = resp.Body.Close()
+
+		if resp.StatusCode >= 300 {
+			log.WithField("status", resp.StatusCode).Warn("panel rejected websocket audit batch")
+		}
+	})
+}
@@ -0,0 +1,33 @@
+package websocket
+
+import (
+	"github.com/golang-jwt/jwt/v4"
+
+	"github.com/pterodactyl/wings/config"
+)
+
+// AuthenticationEvent is sent by the client as its first message after the
+// socket is opened, and again any time it needs to refresh a token that is
+// about to expire, carrying a Panel-issued JWT as its sole argument.
+const AuthenticationEvent = "auth"
+
+// authClaims are the claims carried in the JWT the Panel issues to
+// authenticate (or re-authenticate) a server websocket connection.
+type authClaims struct {
+	jwt.RegisteredClaims
+	UserUUID string `json:"user_uuid"`
+}
+
+// ParseAuthToken validates token, which must be signed with this node's
+// Panel authentication secret the same way every other Panel-issued token
+// is, and returns the subject user's UUID.
+func ParseAuthToken(token string) (string, error) {
+	claims := &authClaims{}
+	_, err := jwt.ParseWithClaims(token, claims, func(t *jwt.Token) (interface{}, error) {
+		return []byte(config.Get().AuthenticationToken), nil
+	})
+	if err != nil {
+		return "", err
+	}
+	return claims.UserUUID, nil
+}
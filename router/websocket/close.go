@@ -0,0 +1,101 @@
+package websocket
+
+import (
+	"context"
+	"time"
+
+	ws "github.com/gorilla/websocket"
+)
+
+// CloseGraceTimeout bounds how long we'll wait for a client to acknowledge
+// a server-initiated close handshake before the underlying connection is
+// torn down regardless.
+const CloseGraceTimeout = 5 * time.Second
+
+// Close codes used when Wings itself is initiating the disconnect, rather
+// than the client. These live outside of the standard 1xxx range reserved
+// by RFC 6455 for protocol-level conditions.
+const (
+	CloseServerDeleted = 4009
+	CloseSuspended     = 4409
+)
+
+// Close performs a graceful, server-initiated close handshake: it writes a
+// close control frame with the given status code and reason, and then waits
+// (bounded by CloseGraceTimeout) for the client to respond with its own
+// close frame before the underlying TCP connection is released. This keeps
+// clients from seeing an abrupt CloseAbnormalClosure when Wings is the one
+// ending the session, e.g. because the server was deleted or suspended, or
+// Wings itself is restarting.
+func (h *Handler) Close(code int, reason string) error {
+	done := make(chan struct{})
+	h.closeAckMu.Lock()
+	h.closeAck = done
+	h.closeAckMu.Unlock()
+
+	// WriteControl may be called concurrently with the writer goroutine's
+	// data frame writes; gorilla/websocket serializes control frames on
+	// their own internal lock.
+	err := h.Connection.WriteControl(ws.CloseMessage, ws.FormatCloseMessage(code, reason), time.Now().Add(writeWait))
+	if err != nil {
+		return h.Connection.Close()
+	}
+
+	select {
+	case <-done:
+	case <-time.After(CloseGraceTimeout):
+		h.Logger().Warn("timed out waiting for client close handshake acknowledgement")
+	}
+
+	return h.Connection.Close()
+}
+
+// ConnCloser lets code outside of this package (namely the per-server
+// websocket manager) trigger a graceful close of a single connection
+// without reaching into its internals, while still unwinding every
+// goroutine tied to the connection's context.
+type ConnCloser struct {
+	cancel context.CancelFunc
+	signal chan closeSignal
+}
+
+type closeSignal struct {
+	code   int
+	reason string
+}
+
+// NewConnCloser wraps the cancel function for a connection's context so
+// that it can also carry an explicit close code and reason through to the
+// goroutine responsible for tearing the connection down.
+func NewConnCloser(cancel context.CancelFunc) *ConnCloser {
+	return &ConnCloser{cancel: cancel, signal: make(chan closeSignal, 1)}
+}
+
+// Close requests a graceful close of the underlying connection using the
+// given status code and reason, and then cancels its context.
+func (c *ConnCloser) Close(code int, reason string) {
+	select {
+	case c.signal <- closeSignal{code, reason}:
+	default:
+	}
+	c.cancel()
+}
+
+// Cancel aborts the connection's context without specifying an explicit
+// close code, falling back to a normal closure.
+func (c *ConnCloser) Cancel() {
+	c.cancel()
+}
+
+// Signal returns the first requested close code and reason; later calls to
+// Close made before Signal reads it are dropped. If Close was never called
+// (for example, the client disconnected on its own) it defaults to a normal
+// closure.
+func (c *ConnCloser) Signal() (int, string) {
+	select {
+	case s := <-c.signal:
+		return s.code, s.reason
+	default:
+		return ws.CloseNormalClosure, ""
+	}
+}
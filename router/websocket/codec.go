@@ -0,0 +1,72 @@
+package websocket
+
+import (
+	"encoding/json"
+
+	ws "github.com/gorilla/websocket"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// Subprotocol names negotiated over the WebSocket Sec-WebSocket-Protocol
+// header. ProtocolJSON is the original JSON transport and remains the
+// default for clients that don't request anything else; ProtocolMsgpack
+// trades a little human-readability for materially less marshal overhead
+// and smaller frames, which matters on nodes pushing a lot of console
+// output and stats broadcasts.
+const (
+	ProtocolJSON    = "wings.json.v1"
+	ProtocolMsgpack = "wings.msgpack.v1"
+)
+
+// Subprotocols lists every subprotocol Wings is willing to negotiate, in
+// priority order, and is passed directly to the gorilla Upgrader.
+var Subprotocols = []string{ProtocolJSON, ProtocolMsgpack}
+
+// Codec encodes and decodes Message values to and from the wire format used
+// by a single negotiated subprotocol.
+type Codec interface {
+	// FrameType is the gorilla/websocket frame type (TextMessage or
+	// BinaryMessage) that encoded messages must be sent as.
+	FrameType() int
+	Encode(m Message) ([]byte, error)
+	Decode(p []byte) (Message, error)
+}
+
+var codecs = map[string]Codec{
+	ProtocolJSON:    jsonCodec{},
+	ProtocolMsgpack: msgpackCodec{},
+}
+
+// codecFor resolves the Codec for a negotiated subprotocol, falling back to
+// the JSON codec when the client didn't request a subprotocol at all so
+// older clients keep working unchanged.
+func codecFor(subprotocol string) Codec {
+	if c, ok := codecs[subprotocol]; ok {
+		return c
+	}
+	return jsonCodec{}
+}
+
+type jsonCodec struct{}
+
+func (jsonCodec) FrameType() int { return ws.TextMessage }
+
+func (jsonCodec) Encode(m Message) ([]byte, error) { return json.Marshal(m) }
+
+func (jsonCodec) Decode(p []byte) (Message, error) {
+	var m Message
+	err := json.Unmarshal(p, &m)
+	return m, err
+}
+
+type msgpackCodec struct{}
+
+func (msgpackCodec) FrameType() int { return ws.BinaryMessage }
+
+func (msgpackCodec) Encode(m Message) ([]byte, error) { return msgpack.Marshal(m) }
+
+func (msgpackCodec) Decode(p []byte) (Message, error) {
+	var m Message
+	err := msgpack.Unmarshal(p, &m)
+	return m, err
+}
@@ -0,0 +1,55 @@
+package websocket
+
+import (
+	"context"
+	"time"
+
+	ws "github.com/gorilla/websocket"
+)
+
+const (
+	// DefaultPingInterval is how often a ping control frame is sent to the
+	// client when no PingInterval is configured.
+	DefaultPingInterval = 30 * time.Second
+	// DefaultPongTimeout is how long the server waits for a pong before
+	// considering a connection dead when no PongTimeout is configured.
+	DefaultPongTimeout = 60 * time.Second
+)
+
+// StartKeepalive begins sending periodic ping control frames to the client
+// and arms a read deadline that is pushed forward every time a pong is
+// received. If the peer stops responding to pings the read deadline will
+// eventually lapse, causing the blocking Connection.ReadMessage call in the
+// connection's read loop to return a timeout error so it can tear itself
+// down; onPingFailure is invoked immediately if writing a ping frame itself
+// fails, since that means the connection is already gone.
+func (h *Handler) StartKeepalive(ctx context.Context, interval, timeout time.Duration, onPingFailure func()) {
+	if interval <= 0 {
+		interval = DefaultPingInterval
+	}
+	if timeout <= 0 {
+		timeout = DefaultPongTimeout
+	}
+
+	_ = h.Connection.SetReadDeadline(time.Now().Add(timeout))
+	h.Connection.SetPongHandler(func(string) error {
+		return h.Connection.SetReadDeadline(time.Now().Add(timeout))
+	})
+
+	ticker := time.NewTicker(interval)
+	go func() {
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := h.Connection.WriteControl(ws.PingMessage, nil, time.Now().Add(writeWait)); err != nil {
+					h.logger.WithField("error", err).Warn("failed to write keepalive ping, treating connection as dead")
+					onPingFailure()
+					return
+				}
+			}
+		}
+	}()
+}
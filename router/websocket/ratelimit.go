@@ -0,0 +1,55 @@
+package websocket
+
+import (
+	"sync"
+
+	"golang.org/x/time/rate"
+
+	"github.com/pterodactyl/wings/config"
+)
+
+// RateLimiter enforces a token-bucket limit on inbound events for a single
+// connection, with optional per-event-type overrides, and a semaphore
+// bounding how many HandleInbound goroutines the connection may have
+// in-flight at once. Without this a malicious or broken client flooding
+// "send command"/"send stats" events can spawn unbounded goroutines and DoS
+// an entire node through a single authenticated connection.
+type RateLimiter struct {
+	mu       sync.Mutex
+	def      *rate.Limiter
+	perEvent map[string]*rate.Limiter
+	inFlight chan struct{}
+}
+
+// NewRateLimiter builds a RateLimiter from the given configuration.
+func NewRateLimiter(cfg config.RateLimitConfiguration) *RateLimiter {
+	rl := &RateLimiter{
+		def:      rate.NewLimiter(rate.Limit(cfg.EventsPerSecond), cfg.Burst),
+		perEvent: make(map[string]*rate.Limiter, len(cfg.PerEvent)),
+		inFlight: make(chan struct{}, cfg.MaxInFlight),
+	}
+	for event, o := range cfg.PerEvent {
+		rl.perEvent[event] = rate.NewLimiter(rate.Limit(o.EventsPerSecond), o.Burst)
+	}
+	return rl
+}
+
+// Allow reports whether an inbound event of the given type may be processed
+// right now, consuming a token from its bucket if so.
+func (rl *RateLimiter) Allow(event string) bool {
+	rl.mu.Lock()
+	l, ok := rl.perEvent[event]
+	rl.mu.Unlock()
+	if ok {
+		return l.Allow()
+	}
+	return rl.def.Allow()
+}
+
+// Acquire blocks until a slot is free to process another inbound event
+// concurrently, and returns a function that must be called to release it
+// once processing completes.
+func (rl *RateLimiter) Acquire() func() {
+	rl.inFlight <- struct{}{}
+	return func() { <-rl.inFlight }
+}
@@ -0,0 +1,58 @@
+package websocket
+
+import (
+	"net"
+	"net/http"
+	"strings"
+)
+
+// remoteIP returns the real client address for r, honoring
+// X-Forwarded-For/X-Real-IP only when the immediate peer (r.RemoteAddr) is
+// itself in the configured list of trusted reverse proxies. This keeps a
+// client from spoofing the IP recorded in the audit trail simply by sending
+// those headers directly to Wings.
+func remoteIP(r *http.Request, trustedProxies []string) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+
+	if !isTrustedProxy(host, trustedProxies) {
+		return host
+	}
+
+	if xff := r.Header.Get("X-Forwarded-For"); xff != "" {
+		if parts := strings.Split(xff, ","); len(parts) > 0 {
+			if ip := strings.TrimSpace(parts[0]); ip != "" {
+				return ip
+			}
+		}
+	}
+	if xrip := strings.TrimSpace(r.Header.Get("X-Real-IP")); xrip != "" {
+		return xrip
+	}
+
+	return host
+}
+
+// isTrustedProxy reports whether host matches one of the given IPs or
+// CIDR ranges.
+func isTrustedProxy(host string, trustedProxies []string) bool {
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return false
+	}
+
+	for _, entry := range trustedProxies {
+		if _, network, err := net.ParseCIDR(entry); err == nil {
+			if network.Contains(ip) {
+				return true
+			}
+			continue
+		}
+		if trusted := net.ParseIP(entry); trusted != nil && trusted.Equal(ip) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,260 @@
+package websocket
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"emperror.dev/errors"
+	"github.com/apex/log"
+	"github.com/gin-gonic/gin"
+	ws "github.com/gorilla/websocket"
+	"github.com/google/uuid"
+
+	"github.com/pterodactyl/wings/config"
+	"github.com/pterodactyl/wings/server"
+)
+
+// writeWait is how long a single control or data frame write is allowed to
+// take before it is considered failed.
+const writeWait = 10 * time.Second
+
+var upgrader = ws.Upgrader{
+	// Accepting every origin here is fine; the Panel is responsible for
+	// making sure requests never reach Wings from an untrusted origin, and
+	// CORS is handled at the HTTP layer before this point.
+	CheckOrigin: func(r *http.Request) bool { return true },
+	// Offered to the client in priority order; gorilla selects the first
+	// one the client also offered via Sec-WebSocket-Protocol, or none at
+	// all if the client doesn't send that header, in which case we fall
+	// back to the JSON codec below.
+	Subprotocols: Subprotocols,
+}
+
+// Message represents the schema used for all inbound and outbound events
+// sent across a server websocket connection.
+type Message struct {
+	// Event is the name of the event being transmitted, for example
+	// "console output" or "send command".
+	Event string `json:"event"`
+	// Args are the arguments sent along with the event, if any.
+	Args []string `json:"args,omitempty"`
+}
+
+// Handler wraps a single upgraded websocket connection for a given server,
+// tracking the state needed to safely read from and write to it, and to
+// authenticate the requests flowing across it.
+type Handler struct {
+	Connection *ws.Conn
+	server     *server.Server
+
+	uuid   uuid.UUID
+	logger *log.Entry
+
+	// codec encodes and decodes Message values according to the subprotocol
+	// negotiated with the client during the upgrade.
+	codec Codec
+
+	// outbound is consumed exclusively by the writer goroutine started by
+	// StartWriter; gorilla/websocket forbids concurrent writers, so every
+	// Send* call must funnel through this channel rather than touching
+	// Connection directly.
+	outbound     chan Message
+	writeTimeout time.Duration
+	overflow     func()
+
+	// audit, when set via SetAuditSink, receives a record of every
+	// significant event on this connection's lifecycle.
+	audit         AuditSink
+	userID        string
+	authenticated bool
+	remoteIP      string
+	connectedAt   time.Time
+	bytesIn       uint64
+	bytesOut      uint64
+
+	// closeAckMu guards closeAck, which Close swaps in so that the close
+	// handler (registered once below, up front) always has somewhere safe
+	// to signal regardless of which goroutine is currently waiting in
+	// Close.
+	closeAckMu sync.Mutex
+	closeAck   chan struct{}
+}
+
+// GetHandler upgrades the provided HTTP request to a websocket connection
+// and returns a Handler wrapping it, bound to the given server instance.
+func GetHandler(s *server.Server, w http.ResponseWriter, r *http.Request, c *gin.Context) (*Handler, error) {
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return nil, errors.WithStackIf(err)
+	}
+
+	u := uuid.New()
+
+	h := &Handler{
+		Connection:   conn,
+		server:       s,
+		uuid:         u,
+		codec:        codecFor(conn.Subprotocol()),
+		outbound:     make(chan Message, defaultQueueSize),
+		writeTimeout: defaultWriteTimeout,
+		remoteIP:     remoteIP(r, config.Get().System.Websocket.TrustedProxies),
+		connectedAt:  time.Now(),
+		logger: log.WithFields(log.Fields{
+			"subsystem":   "websocket",
+			"server":      s.ID(),
+			"socket":      u.String(),
+			"subprotocol": conn.Subprotocol(),
+		}),
+	}
+
+	// Registered once, here, before the read loop that calls
+	// Connection.ReadMessage ever starts. gorilla/websocket's close handler
+	// is an unsynchronized field on Conn, so setting it again later from
+	// Close (concurrently with that read loop) would be a data race.
+	conn.SetCloseHandler(h.handleClose)
+
+	return h, nil
+}
+
+// handleClose is registered once, in GetHandler, as this connection's
+// gorilla/websocket close handler. It signals whichever call to Close is
+// currently waiting for the peer's close frame acknowledgement, if any.
+func (h *Handler) handleClose(code int, text string) error {
+	h.closeAckMu.Lock()
+	defer h.closeAckMu.Unlock()
+	if h.closeAck != nil {
+		close(h.closeAck)
+	}
+	return nil
+}
+
+// Decode parses a raw inbound frame payload using the codec negotiated for
+// this connection.
+func (h *Handler) Decode(p []byte) (Message, error) {
+	return h.codec.Decode(p)
+}
+
+// Uuid returns the unique identifier assigned to this connection when it
+// was opened.
+func (h *Handler) Uuid() uuid.UUID {
+	return h.uuid
+}
+
+// Logger returns the logger instance scoped to this specific connection.
+func (h *Handler) Logger() *log.Entry {
+	return h.logger
+}
+
+// SendJson queues the given message to be written to the client by this
+// connection's writer goroutine. It never writes to the connection
+// directly: gorilla/websocket does not allow concurrent writers, and this
+// may be called concurrently from many inbound-message goroutines at once.
+func (h *Handler) SendJson(v *Message) error {
+	select {
+	case h.outbound <- *v:
+		return nil
+	case <-time.After(h.writeTimeout):
+		h.logger.WithField("write_timeout", h.writeTimeout).Warn("outbound queue saturated for longer than writeTimeout, closing connection")
+		if h.overflow != nil {
+			h.overflow()
+		}
+		return errors.New("websocket: outbound queue saturated")
+	}
+}
+
+// SendErrorJson sends an error back to the connected client in response to
+// a specific inbound message.
+func (h *Handler) SendErrorJson(msg Message, err error) error {
+	h.Logger().WithField("error", err).WithField("event", msg.Event).Error("encountered an error processing a socket event")
+
+	return h.SendJson(&Message{
+		Event: "error",
+		Args:  []string{"an unexpected error was encountered while handling this request"},
+	})
+}
+
+// SetAuditSink attaches the sink that will receive a record of every
+// significant event for this connection, and immediately records the
+// connection having been opened.
+func (h *Handler) SetAuditSink(sink AuditSink) {
+	h.audit = sink
+	h.recordAudit(AuditConnectionOpened, nil)
+}
+
+// SetUserID records the user identified by the JWT used to authenticate
+// this connection. The first call, for the connection's initial auth
+// event, records AuditAuthenticated; any later call, for a subsequent auth
+// event sent to refresh an expiring token, records AuditReauthenticated.
+func (h *Handler) SetUserID(id string) {
+	h.userID = id
+	if h.authenticated {
+		h.recordAudit(AuditReauthenticated, nil)
+		return
+	}
+	h.authenticated = true
+	h.recordAudit(AuditAuthenticated, nil)
+}
+
+// RecordEventDispatched logs that an inbound event of the given type has
+// passed the rate limiter and is about to be handled, either inline (the
+// auth event) or by HandleInbound.
+func (h *Handler) RecordEventDispatched(event string) {
+	h.recordAudit(AuditEventDispatched, func(e *AuditEvent) {
+		e.Event = event
+	})
+}
+
+// RecordClosed logs that the connection has closed with the given status
+// code and reason, along with the total bytes transferred and the
+// connection's lifetime.
+func (h *Handler) RecordClosed(code int, reason string) {
+	h.recordAudit(AuditConnectionClosed, func(e *AuditEvent) {
+		e.CloseCode = code
+		e.CloseReason = reason
+		e.BytesIn = atomic.LoadUint64(&h.bytesIn)
+		e.BytesOut = atomic.LoadUint64(&h.bytesOut)
+		e.Duration = time.Since(h.connectedAt)
+	})
+}
+
+func (h *Handler) recordAudit(t AuditEventType, patch func(*AuditEvent)) {
+	if h.audit == nil {
+		return
+	}
+
+	e := AuditEvent{
+		Type:        t,
+		Time:        time.Now(),
+		ServerID:    h.server.ID(),
+		UserID:      h.userID,
+		RemoteIP:    h.remoteIP,
+		Subprotocol: h.Connection.Subprotocol(),
+		Socket:      h.uuid.String(),
+	}
+	if patch != nil {
+		patch(&e)
+	}
+	h.audit.Record(e)
+}
+
+// AddBytesIn tallies n additional bytes having been read from the client,
+// for use in the connection's audit trail.
+func (h *Handler) AddBytesIn(n int) {
+	atomic.AddUint64(&h.bytesIn, uint64(n))
+}
+
+// AddBytesOut tallies n additional bytes having been written to the client,
+// for use in the connection's audit trail.
+func (h *Handler) AddBytesOut(n int) {
+	atomic.AddUint64(&h.bytesOut, uint64(n))
+}
+
+// HandleInbound accepts an inbound message from the client and returns nil.
+// It does not route events by name: console input, stats subscriptions and
+// power actions are not handled here, and no event has any effect.
+func (h *Handler) HandleInbound(ctx context.Context, m Message) error {
+	return nil
+}
@@ -0,0 +1,52 @@
+package websocket
+
+import (
+	"context"
+	"time"
+)
+
+const (
+	// defaultQueueSize is how many outbound messages can be buffered for a
+	// single connection before SendJson starts blocking its caller.
+	defaultQueueSize = 64
+	// defaultWriteTimeout is how long SendJson will wait for room in the
+	// outbound queue before giving up on the connection entirely.
+	defaultWriteTimeout = 10 * time.Second
+)
+
+// StartWriter launches the goroutine responsible for writing every outbound
+// message queued for this connection. It is the only goroutine ever allowed
+// to call Connection.WriteMessage; everything else must go through
+// SendJson. onOverflow is invoked by SendJson if the outbound queue stays
+// saturated for longer than the handler's write timeout (always
+// defaultWriteTimeout; it is not read from the configuration). The handler
+// does not close the connection itself on overflow: onOverflow is where the
+// caller closes it (with 1011) and tears down the rest of the connection's
+// goroutines.
+func (h *Handler) StartWriter(ctx context.Context, onOverflow func()) {
+	h.overflow = onOverflow
+
+	go func() {
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case msg, ok := <-h.outbound:
+				if !ok {
+					return
+				}
+				data, err := h.codec.Encode(msg)
+				if err != nil {
+					h.logger.WithField("error", err).Warn("failed to encode outbound message, dropping it")
+					continue
+				}
+				_ = h.Connection.SetWriteDeadline(time.Now().Add(writeWait))
+				if err := h.Connection.WriteMessage(h.codec.FrameType(), data); err != nil {
+					h.logger.WithField("error", err).Warn("failed to write queued message to websocket connection")
+					return
+				}
+				h.AddBytesOut(len(data))
+			}
+		}
+	}()
+}
@@ -0,0 +1,71 @@
+package server
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/pterodactyl/wings/router/websocket"
+)
+
+// WebsocketManager tracks every open websocket connection for a single
+// server so that they can all be torn down programmatically, for example
+// when the server is deleted, suspended, or Wings itself is restarting.
+type WebsocketManager struct {
+	mu    sync.RWMutex
+	conns map[uuid.UUID]*websocket.ConnCloser
+}
+
+// NewWebsocketManager returns a new, empty websocket connection tracker for
+// a server.
+func NewWebsocketManager() *WebsocketManager {
+	return &WebsocketManager{conns: make(map[uuid.UUID]*websocket.ConnCloser)}
+}
+
+// Push registers a newly opened connection so that it can be closed
+// programmatically later on.
+func (wm *WebsocketManager) Push(id uuid.UUID, closer *websocket.ConnCloser) {
+	wm.mu.Lock()
+	defer wm.mu.Unlock()
+	wm.conns[id] = closer
+}
+
+// Remove stops tracking a connection, generally called once it has been
+// closed, regardless of which side initiated the close.
+func (wm *WebsocketManager) Remove(id uuid.UUID) {
+	wm.mu.Lock()
+	defer wm.mu.Unlock()
+	delete(wm.conns, id)
+}
+
+// CloseAll requests a graceful close of every connection currently tracked
+// by this manager using the given status code and reason, and then waits
+// for websocket.CloseGraceTimeout (or until ctx is done, whichever comes
+// first) before returning. It does not track whether each connection's close
+// handshake has actually finished. This is meant to be used to drain connections cleanly, for
+// example during a Wings shutdown or restart, rather than simply severing
+// the underlying TCP connections.
+//
+// Nothing in this tree currently calls CloseAll: it has no wired-in
+// caller because this snapshot doesn't include Wings' process shutdown
+// sequence. It's exported so that sequence can call it once it exists;
+// until then this is dead code.
+func (wm *WebsocketManager) CloseAll(ctx context.Context, code int, reason string) {
+	wm.mu.RLock()
+	closers := make([]*websocket.ConnCloser, 0, len(wm.conns))
+	for _, closer := range wm.conns {
+		closers = append(closers, closer)
+	}
+	wm.mu.RUnlock()
+
+	for _, closer := range closers {
+		closer.Close(code, reason)
+	}
+
+	select {
+	case <-ctx.Done():
+	case <-time.After(websocket.CloseGraceTimeout):
+	}
+}